@@ -0,0 +1,42 @@
+package api
+
+import (
+	"time"
+)
+
+// Severity mirrors collectd's notification_severity_e and specifies the
+// importance of a Notification.
+type Severity int
+
+const (
+	// SeverityFailure signals that something is definitely wrong.
+	SeverityFailure Severity = 1
+	// SeverityWarning signals that something may be about to go wrong.
+	SeverityWarning Severity = 2
+	// SeverityOkay signals that a previously reported problem is resolved.
+	SeverityOkay Severity = 4
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityFailure:
+		return "FAILURE"
+	case SeverityWarning:
+		return "WARNING"
+	case SeverityOkay:
+		return "OKAY"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Notification represents an event such as a threshold breach, mirroring
+// collectd's notification_t.
+type Notification struct {
+	Identifier
+
+	Severity Severity
+	Time     time.Time
+	Message  string
+	Metadata Metadata
+}
@@ -0,0 +1,260 @@
+// +build go1.5,cgo
+
+package plugin
+
+// #include "plugin.h"
+//
+// int register_complex_config_wrapper(char const *name,
+//     plugin_complex_config_cb callback, user_data_t *ud);
+// int wrap_config_callback(oconfig_item_t *, user_data_t *);
+import "C"
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unsafe"
+)
+
+// Value represents a single configuration value, which is one of String,
+// Number or Boolean, mirroring the oconfig_value_t union.
+type Value interface {
+	isConfigValue()
+}
+
+// String is a Value holding a quoted configuration string.
+type String string
+
+// Number is a Value holding a numeric configuration value.
+type Number float64
+
+// Boolean is a Value holding a boolean configuration value.
+type Boolean bool
+
+func (String) isConfigValue()  {}
+func (Number) isConfigValue()  {}
+func (Boolean) isConfigValue() {}
+
+// Block represents a single configuration block or key-value line as
+// parsed by collectd from collectd.conf, e.g.
+//
+//   <Plugin example>
+//     Host "example.com"
+//     <Page "/">
+//       Interval 10
+//     </Page>
+//   </Plugin>
+//
+// mirrors collectd's oconfig_item_t tree.
+type Block struct {
+	Key      string
+	Values   []Value
+	Children []Block
+}
+
+// Configurer is implemented by plugins that want to receive the
+// configuration block registered for them via RegisterConfig.
+type Configurer interface {
+	Config(Block) error
+}
+
+// configFuncs holds references to all config callbacks, so the garbage
+// collector doesn't get any funny ideas.
+var configFuncs = make(map[pluginKey]Configurer)
+
+func newBlock(item *C.oconfig_item_t) Block {
+	b := Block{
+		Key: C.GoString(item.key),
+	}
+
+	if n := int(item.values_num); n > 0 {
+		values := (*[1 << 20]C.oconfig_value_t)(unsafe.Pointer(item.values))[:n:n]
+		for _, v := range values {
+			switch v._type {
+			case C.OCONFIG_TYPE_STRING:
+				b.Values = append(b.Values, String(C.GoString(C.oconfig_value_string(&v))))
+			case C.OCONFIG_TYPE_NUMBER:
+				b.Values = append(b.Values, Number(C.oconfig_value_number(&v)))
+			case C.OCONFIG_TYPE_BOOLEAN:
+				b.Values = append(b.Values, Boolean(C.oconfig_value_boolean(&v) != 0))
+			}
+		}
+	}
+
+	if n := int(item.children_num); n > 0 {
+		children := (*[1 << 20]C.oconfig_item_t)(unsafe.Pointer(item.children))[:n:n]
+		for i := range children {
+			b.Children = append(b.Children, newBlock(&children[i]))
+		}
+	}
+
+	return b
+}
+
+// RegisterConfig registers a Configurer which receives the "<Plugin name>"
+// block, if any, from collectd.conf. It is bound to collectd's
+// plugin_register_complex_config().
+func RegisterConfig(name string, c Configurer) error {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+
+	key := pluginKey{path: callerPluginPath(1), name: name}
+	ud := C.user_data_t{
+		data:      unsafe.Pointer(C.CString(key.encode())),
+		free_func: nil,
+	}
+
+	status, err := C.register_complex_config_wrapper(cName,
+		C.plugin_complex_config_cb(C.wrap_config_callback), &ud)
+	if err != nil {
+		return err
+	} else if status != 0 {
+		return fmt.Errorf("register_complex_config_wrapper failed with status %d", status)
+	}
+
+	configFuncs[key] = c
+	return nil
+}
+
+//export wrap_config_callback
+func wrap_config_callback(ci *C.oconfig_item_t, ud *C.user_data_t) C.int {
+	key := decodeKey(C.GoString((*C.char)(ud.data)))
+	c, ok := configFuncs[key]
+	if !ok {
+		return -1
+	}
+
+	if err := c.Config(newBlock(ci)); err != nil {
+		Errorf("%s plugin: Config() failed: %v", key.name, err)
+		return -1
+	}
+
+	return 0
+}
+
+// Unmarshal decodes a Block into the struct pointed to by v. Each child
+// Block is matched, case-insensitively, against the exported field name of
+// v or a `config:"..."` struct tag. String, bool, float64 and int fields
+// are populated from the child's first Value; []string, []float64 and
+// []bool fields accumulate every Value of every child sharing that key, so
+// repeated simple directives (e.g. two separate `Tag "a"` / `Tag "b"`
+// lines) all end up in the slice rather than the later one overwriting the
+// former; a repeated child Block maps onto a slice of structs, and a
+// nested Block maps onto a nested struct.
+func Unmarshal(b Block, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("plugin: Unmarshal needs a pointer to a struct, got %T", v)
+	}
+	elem := rv.Elem()
+
+	for _, child := range b.Children {
+		idx := fieldIndex(elem.Type(), child.Key)
+		if idx < 0 {
+			continue
+		}
+		field := elem.Field(idx)
+
+		if field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Struct {
+			n := reflect.Append(field, reflect.Zero(field.Type().Elem()))
+			field.Set(n)
+			if err := Unmarshal(child, field.Index(field.Len()-1).Addr().Interface()); err != nil {
+				return fmt.Errorf("%s: %v", child.Key, err)
+			}
+			continue
+		}
+
+		if field.Kind() == reflect.Struct {
+			if err := Unmarshal(child, field.Addr().Interface()); err != nil {
+				return fmt.Errorf("%s: %v", child.Key, err)
+			}
+			continue
+		}
+
+		if field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.Struct {
+			if err := appendField(field, child.Values); err != nil {
+				return fmt.Errorf("%s: %v", child.Key, err)
+			}
+			continue
+		}
+
+		if err := setField(field, child.Values); err != nil {
+			return fmt.Errorf("%s: %v", child.Key, err)
+		}
+	}
+
+	return nil
+}
+
+func fieldIndex(t reflect.Type, key string) int {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			// Unexported field: not settable via reflection, so it
+			// can never be a valid match for a config directive.
+			continue
+		}
+		tag := f.Tag.Get("config")
+		if tag == "" {
+			tag = f.Name
+		}
+		if strings.EqualFold(tag, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// appendField appends values to field, a scalar-element slice, so that
+// repeated directives sharing the same key (the normal way collectd
+// represents a list, e.g. two separate "Tag \"a\"" / "Tag \"b\"" lines)
+// accumulate instead of the later one overwriting the former.
+func appendField(field reflect.Value, values []Value) error {
+	for _, v := range values {
+		elem := reflect.New(field.Type().Elem()).Elem()
+		if err := setScalar(elem, v); err != nil {
+			return err
+		}
+		field.Set(reflect.Append(field, elem))
+	}
+	return nil
+}
+
+func setField(field reflect.Value, values []Value) error {
+	if len(values) == 0 {
+		return fmt.Errorf("no value given")
+	}
+	return setScalar(field, values[0])
+}
+
+func setScalar(field reflect.Value, v Value) error {
+	switch field.Kind() {
+	case reflect.String:
+		s, ok := v.(String)
+		if !ok {
+			return fmt.Errorf("expected a string, got %T", v)
+		}
+		field.SetString(string(s))
+	case reflect.Bool:
+		b, ok := v.(Boolean)
+		if !ok {
+			return fmt.Errorf("expected a boolean, got %T", v)
+		}
+		field.SetBool(bool(b))
+	case reflect.Float32, reflect.Float64:
+		n, ok := v.(Number)
+		if !ok {
+			return fmt.Errorf("expected a number, got %T", v)
+		}
+		field.SetFloat(float64(n))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := v.(Number)
+		if !ok {
+			return fmt.Errorf("expected a number, got %T", v)
+		}
+		field.SetInt(int64(n))
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Kind())
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+// +build go1.5,cgo
+
+package plugin
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testConfig struct {
+	Host    string
+	Port    int
+	Enabled bool
+	Tags    []string
+	Page    testPageConfig   `config:"Page"`
+	Pages   []testPageConfig `config:"Page2"`
+}
+
+type testPageConfig struct {
+	Interval float64
+}
+
+func TestUnmarshal(t *testing.T) {
+	b := Block{
+		Children: []Block{
+			{Key: "Host", Values: []Value{String("example.com")}},
+			{Key: "Port", Values: []Value{Number(8080)}},
+			{Key: "Enabled", Values: []Value{Boolean(true)}},
+			{Key: "Tags", Values: []Value{String("a")}},
+			{Key: "Tags", Values: []Value{String("b")}},
+			{Key: "Page", Values: []Value{String("/")}, Children: []Block{
+				{Key: "Interval", Values: []Value{Number(10)}},
+			}},
+		},
+	}
+
+	var got testConfig
+	if err := Unmarshal(b, &got); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	want := testConfig{
+		Host:    "example.com",
+		Port:    8080,
+		Enabled: true,
+		Tags:    []string{"a", "b"},
+		Page:    testPageConfig{Interval: 10},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unmarshal() = %+v, want %+v", got, want)
+	}
+}
+
+func TestUnmarshalRepeatedBlock(t *testing.T) {
+	b := Block{
+		Children: []Block{
+			{Key: "Page2", Children: []Block{{Key: "Interval", Values: []Value{Number(1)}}}},
+			{Key: "Page2", Children: []Block{{Key: "Interval", Values: []Value{Number(2)}}}},
+		},
+	}
+
+	var got testConfig
+	if err := Unmarshal(b, &got); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	want := []testPageConfig{{Interval: 1}, {Interval: 2}}
+	if !reflect.DeepEqual(got.Pages, want) {
+		t.Errorf("Pages = %+v, want %+v", got.Pages, want)
+	}
+}
+
+func TestUnmarshalNotAPointer(t *testing.T) {
+	if err := Unmarshal(Block{}, testConfig{}); err == nil {
+		t.Error("Unmarshal() with a non-pointer argument: got nil error, want non-nil")
+	}
+}
@@ -0,0 +1,222 @@
+// +build go1.5,cgo
+
+package plugin
+
+// #include "plugin.h"
+//
+// int register_init_wrapper (char *, plugin_init_cb, user_data_t *);
+// int wrap_init_callback(user_data_t *);
+//
+// int register_flush_wrapper (char const *, plugin_flush_cb, user_data_t *);
+// int wrap_flush_callback(cdtime_t, char const *, user_data_t *);
+//
+// int register_missing_wrapper (char const *, plugin_missing_cb, user_data_t *);
+// int wrap_missing_callback(value_list_t const *, user_data_t *);
+import "C"
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unsafe"
+
+	"collectd.org/api"
+	"collectd.org/cdtime"
+)
+
+// Initer defines the interface for init callbacks, i.e. Go functions that
+// are called once, before the first read callback is called, to do
+// one-time setup such as opening sockets or database handles.
+type Initer interface {
+	Init() error
+}
+
+// initFuncs holds references to all init callbacks, keyed by the
+// pluginKey they were registered under, so the garbage collector doesn't
+// get any funny ideas and two plugins sharing a name don't clobber each
+// other's entries.
+var initFuncs = make(map[pluginKey]Initer)
+
+//export wrap_init_callback
+func wrap_init_callback(ud *C.user_data_t) C.int {
+	key := decodeKey(C.GoString((*C.char)(ud.data)))
+	i, ok := initFuncs[key]
+	if !ok {
+		return 0
+	}
+
+	if err := i.Init(); err != nil {
+		Errorf("%s plugin: Init() failed: %v", key.name, err)
+		return -1
+	}
+	return 0
+}
+
+// RegisterInit registers an Initer whose Init() method is called once,
+// before the first read callback, to perform one-time setup.
+func RegisterInit(name string, i Initer) error {
+	cName := C.CString(name)
+
+	key := pluginKey{path: callerPluginPath(1), name: name}
+	ud := C.user_data_t{
+		data:      unsafe.Pointer(C.CString(key.encode())),
+		free_func: nil,
+	}
+
+	status, err := C.register_init_wrapper(cName,
+		C.plugin_init_cb(C.wrap_init_callback), &ud)
+	if err != nil {
+		return err
+	} else if status != 0 {
+		return fmt.Errorf("register_init_wrapper failed with status %d", status)
+	}
+
+	initFuncs[key] = i
+	return nil
+}
+
+// parseIdentifier parses collectd's "host/plugin-instance/type-instance"
+// textual identifier. An empty string, as collectd sends for a global
+// flush ("flush everything"), yields a zero-value Identifier rather than
+// an error.
+func parseIdentifier(s string) (api.Identifier, error) {
+	if s == "" {
+		return api.Identifier{}, nil
+	}
+
+	parts := strings.SplitN(s, "/", 3)
+	if len(parts) != 3 {
+		return api.Identifier{}, fmt.Errorf("invalid identifier %q", s)
+	}
+
+	id := api.Identifier{Host: parts[0]}
+	id.Plugin, id.PluginInstance = splitInstance(parts[1])
+	id.Type, id.TypeInstance = splitInstance(parts[2])
+	return id, nil
+}
+
+func splitInstance(s string) (string, string) {
+	if i := strings.Index(s, "-"); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
+
+// Flusher defines the interface for flush callbacks, i.e. Go functions
+// that are called when collectd is asked to flush cached values, e.g. by
+// the "FLUSH" unixsock command.
+type Flusher interface {
+	Flush(ctx context.Context, timeout time.Duration, identifier api.Identifier) error
+}
+
+// flushFuncs holds references to all flush callbacks, keyed by the
+// pluginKey they were registered under, so the garbage collector doesn't
+// get any funny ideas and two plugins sharing a name don't clobber each
+// other's entries.
+var flushFuncs = make(map[pluginKey]Flusher)
+
+// RegisterFlush registers a Flusher with the daemon, bound to
+// plugin_register_flush().
+func RegisterFlush(name string, f Flusher) error {
+	cName := C.CString(name)
+
+	key := pluginKey{path: callerPluginPath(1), name: name}
+	ud := C.user_data_t{
+		data:      unsafe.Pointer(C.CString(key.encode())),
+		free_func: nil,
+	}
+
+	status, err := C.register_flush_wrapper(cName,
+		C.plugin_flush_cb(C.wrap_flush_callback), &ud)
+	if err != nil {
+		return err
+	} else if status != 0 {
+		return fmt.Errorf("register_flush_wrapper failed with status %d", status)
+	}
+
+	flushFuncs[key] = f
+	return nil
+}
+
+//export wrap_flush_callback
+func wrap_flush_callback(timeout C.cdtime_t, identifier *C.char, ud *C.user_data_t) C.int {
+	key := decodeKey(C.GoString((*C.char)(ud.data)))
+	f, ok := flushFuncs[key]
+	if !ok {
+		return -1
+	}
+
+	id, err := parseIdentifier(C.GoString(identifier))
+	if err != nil {
+		Errorf("%s plugin: Flush() failed: %v", key.name, err)
+		return -1
+	}
+
+	if err := f.Flush(ctx, cdtime.Time(timeout).Duration(), id); err != nil {
+		Errorf("%s plugin: Flush() failed: %v", key.name, err)
+		return -1
+	}
+
+	return 0
+}
+
+// Misser defines the interface for missing callbacks, i.e. Go functions
+// that are called when an expected value list has not been received
+// within 1.25 times its interval.
+type Misser interface {
+	Missing(ctx context.Context, identifier api.Identifier) error
+}
+
+// missingFuncs holds references to all missing callbacks, keyed by the
+// pluginKey they were registered under, so the garbage collector doesn't
+// get any funny ideas and two plugins sharing a name don't clobber each
+// other's entries.
+var missingFuncs = make(map[pluginKey]Misser)
+
+// RegisterMissing registers a Misser with the daemon, bound to
+// plugin_register_missing().
+func RegisterMissing(name string, m Misser) error {
+	cName := C.CString(name)
+
+	key := pluginKey{path: callerPluginPath(1), name: name}
+	ud := C.user_data_t{
+		data:      unsafe.Pointer(C.CString(key.encode())),
+		free_func: nil,
+	}
+
+	status, err := C.register_missing_wrapper(cName,
+		C.plugin_missing_cb(C.wrap_missing_callback), &ud)
+	if err != nil {
+		return err
+	} else if status != 0 {
+		return fmt.Errorf("register_missing_wrapper failed with status %d", status)
+	}
+
+	missingFuncs[key] = m
+	return nil
+}
+
+//export wrap_missing_callback
+func wrap_missing_callback(cvl *C.value_list_t, ud *C.user_data_t) C.int {
+	key := decodeKey(C.GoString((*C.char)(ud.data)))
+	m, ok := missingFuncs[key]
+	if !ok {
+		return -1
+	}
+
+	id := api.Identifier{
+		Host:           C.GoString(&cvl.host[0]),
+		Plugin:         C.GoString(&cvl.plugin[0]),
+		PluginInstance: C.GoString(&cvl.plugin_instance[0]),
+		Type:           C.GoString(&cvl._type[0]),
+		TypeInstance:   C.GoString(&cvl.type_instance[0]),
+	}
+
+	if err := m.Missing(ctx, id); err != nil {
+		Errorf("%s plugin: Missing() failed: %v", key.name, err)
+		return -1
+	}
+
+	return 0
+}
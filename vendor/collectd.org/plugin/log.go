@@ -0,0 +1,183 @@
+// +build go1.5,cgo
+
+package plugin
+
+// #include "plugin.h"
+//
+// void plugin_log_wrapper(int severity, char const *message);
+// int register_log_wrapper (char const *, plugin_log_cb, user_data_t *);
+// void wrap_log_callback(int, char const *, user_data_t *);
+import "C"
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unsafe"
+)
+
+// Severity mirrors the syslog-style priorities collectd's logging
+// subsystem uses, as passed to plugin_log() and plugin_register_log().
+type Severity int
+
+// Severities as defined by collectd's plugin.h, from most to least
+// critical.
+const (
+	LogErr     Severity = C.LOG_ERR
+	LogWarning Severity = C.LOG_WARNING
+	LogNotice  Severity = C.LOG_NOTICE
+	LogInfo    Severity = C.LOG_INFO
+	LogDebug   Severity = C.LOG_DEBUG
+)
+
+// Log sends message to the daemon's logging subsystem at the given
+// severity, by calling plugin_log().
+func Log(severity Severity, message string) {
+	cMessage := C.CString(message)
+	defer C.free(unsafe.Pointer(cMessage))
+
+	C.plugin_log_wrapper(C.int(severity), cMessage)
+}
+
+// Errorf formats its arguments according to format and logs the resulting
+// string at LogErr.
+func Errorf(format string, a ...interface{}) {
+	Log(LogErr, fmt.Sprintf(format, a...))
+}
+
+// Warningf formats its arguments according to format and logs the
+// resulting string at LogWarning.
+func Warningf(format string, a ...interface{}) {
+	Log(LogWarning, fmt.Sprintf(format, a...))
+}
+
+// Infof formats its arguments according to format and logs the resulting
+// string at LogInfo.
+func Infof(format string, a ...interface{}) {
+	Log(LogInfo, fmt.Sprintf(format, a...))
+}
+
+// Debugf formats its arguments according to format and logs the
+// resulting string at LogDebug.
+func Debugf(format string, a ...interface{}) {
+	Log(LogDebug, fmt.Sprintf(format, a...))
+}
+
+// Logger defines the interface for log callbacks, i.e. Go functions that
+// are called whenever any plugin, including collectd itself, logs a
+// message.
+type Logger interface {
+	Log(severity Severity, message string)
+}
+
+// logFuncs holds references to all log callbacks, keyed by the pluginKey
+// they were registered under, so the garbage collector doesn't get any
+// funny ideas and two plugins sharing a name don't clobber each other's
+// entries.
+var logFuncs = make(map[pluginKey]Logger)
+
+// RegisterLog registers a Logger with the daemon, bound to
+// plugin_register_log(). Once registered, l.Log() is called for every
+// message logged by any plugin.
+func RegisterLog(name string, l Logger) error {
+	cName := C.CString(name)
+
+	key := pluginKey{path: callerPluginPath(1), name: name}
+	ud := C.user_data_t{
+		data:      unsafe.Pointer(C.CString(key.encode())),
+		free_func: nil,
+	}
+
+	status, err := C.register_log_wrapper(cName,
+		C.plugin_log_cb(C.wrap_log_callback), &ud)
+	if err != nil {
+		return err
+	} else if status != 0 {
+		return fmt.Errorf("register_log_wrapper failed with status %d", status)
+	}
+
+	logFuncs[key] = l
+	return nil
+}
+
+//export wrap_log_callback
+func wrap_log_callback(severity C.int, message *C.char, ud *C.user_data_t) {
+	key := decodeKey(C.GoString((*C.char)(ud.data)))
+	l, ok := logFuncs[key]
+	if !ok {
+		return
+	}
+
+	l.Log(Severity(severity), C.GoString(message))
+}
+
+// slogHandler implements slog.Handler by forwarding records to collectd's
+// plugin_log().
+type slogHandler struct {
+	attrs []slog.Attr
+	group string
+}
+
+// NewSlogHandler returns an slog.Handler that forwards slog.Records to
+// collectd's plugin_log(), mapping slog levels to the Severity constants
+// above (DEBUG -> LogDebug, INFO -> LogInfo, WARN -> LogWarning, ERROR ->
+// LogErr). Attributes, including those from WithAttrs/WithGroup, are
+// rendered as "key=value" pairs appended to the message.
+func NewSlogHandler() slog.Handler {
+	return &slogHandler{}
+}
+
+func (h *slogHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *slogHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	b.WriteString(r.Message)
+
+	attrs := append([]slog.Attr(nil), h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+
+	for _, a := range attrs {
+		key := a.Key
+		if h.group != "" {
+			key = h.group + "." + key
+		}
+		fmt.Fprintf(&b, " %s=%v", key, a.Value)
+	}
+
+	Log(severityFromLevel(r.Level), b.String())
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &slogHandler{
+		attrs: append(append([]slog.Attr(nil), h.attrs...), attrs...),
+		group: h.group,
+	}
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	group := name
+	if h.group != "" {
+		group = h.group + "." + name
+	}
+	return &slogHandler{attrs: h.attrs, group: group}
+}
+
+func severityFromLevel(l slog.Level) Severity {
+	switch {
+	case l >= slog.LevelError:
+		return LogErr
+	case l >= slog.LevelWarn:
+		return LogWarning
+	case l >= slog.LevelInfo:
+		return LogInfo
+	default:
+		return LogDebug
+	}
+}
@@ -0,0 +1,196 @@
+// +build go1.5,cgo
+
+package plugin
+
+// #include "plugin.h"
+//
+// int dispatch_notification_wrapper(notification_t const *);
+// int register_notification_wrapper(char const *, plugin_notification_cb,
+//     user_data_t *);
+// int wrap_notification_callback(notification_t const *, user_data_t *);
+//
+// int meta_data_toc_wrapper(meta_data_t *md, char ***toc);
+// int meta_data_type_wrapper(meta_data_t *md, char const *key);
+// int meta_data_get_string_wrapper(meta_data_t *md, char const *key,
+//     char **value);
+// int meta_data_get_signed_int_wrapper(meta_data_t *md, char const *key,
+//     int64_t *value);
+// int meta_data_get_unsigned_int_wrapper(meta_data_t *md, char const *key,
+//     uint64_t *value);
+// int meta_data_get_double_wrapper(meta_data_t *md, char const *key,
+//     double *value);
+// int meta_data_get_boolean_wrapper(meta_data_t *md, char const *key,
+//     _Bool *value);
+import "C"
+
+import (
+	"context"
+	"fmt"
+	"unsafe"
+
+	"collectd.org/api"
+	"collectd.org/cdtime"
+)
+
+// metadataFromC walks a meta_data_t and converts it into an api.Metadata,
+// the inverse of newMetaDataT.
+func metadataFromC(md *C.meta_data_t) api.Metadata {
+	if md == nil {
+		return nil
+	}
+
+	var toc **C.char
+	n := int(C.meta_data_toc_wrapper(md, &toc))
+	if n <= 0 {
+		return nil
+	}
+	defer C.free(unsafe.Pointer(toc))
+
+	keys := (*[1 << 20]*C.char)(unsafe.Pointer(toc))[:n:n]
+
+	meta := make(api.Metadata, n)
+	for _, k := range keys {
+		key := C.GoString(k)
+
+		switch C.meta_data_type_wrapper(md, k) {
+		case C.MD_TYPE_STRING:
+			var v *C.char
+			C.meta_data_get_string_wrapper(md, k, &v)
+			meta[key] = C.GoString(v)
+			C.free(unsafe.Pointer(v))
+		case C.MD_TYPE_SIGNED_INT:
+			var v C.int64_t
+			C.meta_data_get_signed_int_wrapper(md, k, &v)
+			meta[key] = int64(v)
+		case C.MD_TYPE_UNSIGNED_INT:
+			var v C.uint64_t
+			C.meta_data_get_unsigned_int_wrapper(md, k, &v)
+			meta[key] = uint64(v)
+		case C.MD_TYPE_DOUBLE:
+			var v C.double
+			C.meta_data_get_double_wrapper(md, k, &v)
+			meta[key] = float64(v)
+		case C.MD_TYPE_BOOLEAN:
+			var v C._Bool
+			C.meta_data_get_boolean_wrapper(md, k, &v)
+			meta[key] = bool(v)
+		}
+
+		C.free(unsafe.Pointer(k))
+	}
+
+	return meta
+}
+
+func newNotificationT(n *api.Notification) (*C.notification_t, error) {
+	ret := &C.notification_t{
+		severity: C.int(n.Severity),
+		time:     C.cdtime_t(cdtime.New(n.Time)),
+	}
+
+	strcpy(ret.host[:], n.Host)
+	strcpy(ret.plugin[:], n.Plugin)
+	strcpy(ret.plugin_instance[:], n.PluginInstance)
+	strcpy(ret._type[:], n.Type)
+	strcpy(ret.type_instance[:], n.TypeInstance)
+	strcpy(ret.message[:], n.Message)
+
+	if len(n.Metadata) > 0 {
+		meta, err := newMetaDataT(n.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("building metadata: %v", err)
+		}
+		ret.meta = meta
+	}
+
+	return ret, nil
+}
+
+// DispatchNotification sends a notification to the daemon by calling
+// plugin_dispatch_notification().
+func DispatchNotification(n *api.Notification) error {
+	nt, err := newNotificationT(n)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if nt.meta != nil {
+			C.free(unsafe.Pointer(nt.meta))
+		}
+	}()
+
+	status, err := C.dispatch_notification_wrapper(nt)
+	if err != nil {
+		return err
+	} else if status != 0 {
+		return fmt.Errorf("dispatch_notification failed with status %d", status)
+	}
+
+	return nil
+}
+
+// Notifier defines the interface for notification callbacks, i.e. Go
+// functions that are called whenever another plugin dispatches a
+// notification.
+type Notifier interface {
+	Notify(context.Context, *api.Notification) error
+}
+
+// notificationFuncs holds references to all notification callbacks, keyed
+// by the pluginKey they were registered under, so the garbage collector
+// doesn't get any funny ideas and two plugins sharing a name don't
+// clobber each other's entries.
+var notificationFuncs = make(map[pluginKey]Notifier)
+
+// RegisterNotification registers a new notification function with the
+// daemon which is called whenever a notification is dispatched.
+func RegisterNotification(name string, n Notifier) error {
+	cName := C.CString(name)
+
+	key := pluginKey{path: callerPluginPath(1), name: name}
+	ud := C.user_data_t{
+		data:      unsafe.Pointer(C.CString(key.encode())),
+		free_func: nil,
+	}
+
+	status, err := C.register_notification_wrapper(cName,
+		C.plugin_notification_cb(C.wrap_notification_callback), &ud)
+	if err != nil {
+		return err
+	} else if status != 0 {
+		return fmt.Errorf("register_notification_wrapper failed with status %d", status)
+	}
+
+	notificationFuncs[key] = n
+	return nil
+}
+
+//export wrap_notification_callback
+func wrap_notification_callback(n *C.notification_t, ud *C.user_data_t) C.int {
+	key := decodeKey(C.GoString((*C.char)(ud.data)))
+	nf, ok := notificationFuncs[key]
+	if !ok {
+		return -1
+	}
+
+	notif := &api.Notification{
+		Identifier: api.Identifier{
+			Host:           C.GoString(&n.host[0]),
+			Plugin:         C.GoString(&n.plugin[0]),
+			PluginInstance: C.GoString(&n.plugin_instance[0]),
+			Type:           C.GoString(&n._type[0]),
+			TypeInstance:   C.GoString(&n.type_instance[0]),
+		},
+		Severity: api.Severity(n.severity),
+		Time:     cdtime.Time(n.time).Time(),
+		Message:  C.GoString(&n.message[0]),
+		Metadata: metadataFromC(n.meta),
+	}
+
+	if err := nf.Notify(ctx, notif); err != nil {
+		Errorf("%s plugin: Notify() failed: %v", key.name, err)
+		return -1
+	}
+
+	return 0
+}
@@ -95,8 +95,8 @@ package plugin // import "collectd.org/plugin"
 // int register_write_wrapper (char const *, plugin_write_cb, user_data_t *);
 // int wrap_write_callback(data_set_t *, value_list_t *, user_data_t *);
 //
-// int register_shutdown_wrapper (char *, plugin_shutdown_cb);
-// int wrap_shutdown_callback(void);
+// int register_shutdown_wrapper (char *, plugin_shutdown_cb, user_data_t *);
+// int wrap_shutdown_callback(user_data_t *);
 //
 // meta_data_t *meta_data_create_wrapper(void);
 // meta_data_t *meta_data_destroy_wrapper(meta_data_t *);
@@ -116,6 +116,8 @@ import "C"
 import (
 	"context"
 	"fmt"
+	"runtime"
+	"strings"
 	"time"
 	"unsafe"
 
@@ -131,6 +133,84 @@ var (
 	ctx = context.Background()
 )
 
+// pluginKey namespaces a registered callback by both its name and the
+// import path of the plugin that registered it, so that two Go plugins
+// loaded into the same collectd process can register callbacks under the
+// same name without clobbering each other's entries.
+type pluginKey struct {
+	path string
+	name string
+}
+
+// keySeparator joins a pluginKey's path and name before it is handed to
+// C.CString and threaded through a user_data_t's void* data field. It
+// must not be the NUL byte: C.GoString() stops at the first NUL, which
+// would truncate the path back out on the way back from C.
+const keySeparator = "\x1f"
+
+// encode renders a pluginKey as a single C string suitable for a
+// user_data_t's void* data field.
+func (k pluginKey) encode() string {
+	return k.path + keySeparator + k.name
+}
+
+func decodeKey(s string) pluginKey {
+	if i := strings.Index(s, keySeparator); i >= 0 {
+		return pluginKey{path: s[:i], name: s[i+len(keySeparator):]}
+	}
+	return pluginKey{name: s}
+}
+
+// overridePluginPath, if set via SetPluginPath, is used to namespace
+// callbacks instead of the caller's import path.
+var overridePluginPath string
+
+// SetPluginPath overrides the import path used to namespace this
+// plugin's registered callbacks. Most plugins never need to call this:
+// the import path is otherwise derived automatically, via runtime.Caller,
+// from whichever package calls RegisterRead, RegisterWrite or
+// RegisterShutdown.
+func SetPluginPath(path string) {
+	overridePluginPath = path
+}
+
+// callerPluginPath returns the import path of the function skip frames
+// above its own, i.e. skip=0 returns the import path of whatever called
+// callerPluginPath directly.
+func callerPluginPath(skip int) string {
+	if overridePluginPath != "" {
+		return overridePluginPath
+	}
+
+	pc, _, _, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return ""
+	}
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return ""
+	}
+	return funcImportPath(fn.Name())
+}
+
+// funcImportPath extracts the import path from a runtime.Func's Name(),
+// e.g. both "collectd.org/plugin.RegisterRead" and
+// "example.com/goplug.(*ExamplePlugin).Read" yield their package's
+// import path. A name without a "/" (e.g. "main.init") is returned
+// unchanged, function name included.
+func funcImportPath(funcName string) string {
+	slash := strings.LastIndex(funcName, "/")
+	if slash < 0 {
+		// No import path component (e.g. "main.init"): nothing to strip.
+		return funcName
+	}
+	dot := strings.Index(funcName[slash+1:], ".")
+	if dot < 0 {
+		return funcName
+	}
+	return funcName[:slash+1+dot]
+}
+
 // Reader defines the interface for read callbacks, i.e. Go functions that are
 // called periodically from the collectd daemon.
 type Reader interface {
@@ -257,7 +337,7 @@ func Write(vl *api.ValueList) error {
 
 // readFuncs holds references to all read callbacks, so the garbage collector
 // doesn't get any funny ideas.
-var readFuncs = make(map[string]Reader)
+var readFuncs = make(map[pluginKey]Reader)
 
 // ComplexReadConfig represents the extra configuration settings available
 // in the RegisterComplexRead function
@@ -285,8 +365,10 @@ func registerComplexRead(name string, r Reader, config ComplexReadConfig) error
 	defer C.free(unsafe.Pointer(cGroup))
 
 	cName := C.CString(name)
+
+	key := pluginKey{path: callerPluginPath(2), name: name}
 	ud := C.user_data_t{
-		data:      unsafe.Pointer(cName),
+		data:      unsafe.Pointer(C.CString(key.encode())),
 		free_func: nil,
 	}
 
@@ -300,7 +382,7 @@ func registerComplexRead(name string, r Reader, config ComplexReadConfig) error
 		return fmt.Errorf("register_read_wrapper failed with status %d", status)
 	}
 
-	readFuncs[name] = r
+	readFuncs[key] = r
 	return nil
 }
 
@@ -323,14 +405,14 @@ func RegisterComplexRead(name string, r Reader, config ComplexReadConfig) error
 
 //export wrap_read_callback
 func wrap_read_callback(ud *C.user_data_t) C.int {
-	name := C.GoString((*C.char)(ud.data))
-	r, ok := readFuncs[name]
+	key := decodeKey(C.GoString((*C.char)(ud.data)))
+	r, ok := readFuncs[key]
 	if !ok {
 		return -1
 	}
 
 	if err := r.Read(); err != nil {
-		Errorf("%s plugin: Read() failed: %v", name, err)
+		Errorf("%s plugin: Read() failed: %v", key.name, err)
 		return -1
 	}
 
@@ -339,7 +421,7 @@ func wrap_read_callback(ud *C.user_data_t) C.int {
 
 // writeFuncs holds references to all write callbacks, so the garbage collector
 // doesn't get any funny ideas.
-var writeFuncs = make(map[string]api.Writer)
+var writeFuncs = make(map[pluginKey]api.Writer)
 
 // RegisterWrite registers a new write function with the daemon which is called
 // for every metric collected by collectd.
@@ -349,8 +431,10 @@ var writeFuncs = make(map[string]api.Writer)
 // implement appropriate locking around these accesses.
 func RegisterWrite(name string, w api.Writer) error {
 	cName := C.CString(name)
+
+	key := pluginKey{path: callerPluginPath(1), name: name}
 	ud := C.user_data_t{
-		data:      unsafe.Pointer(cName),
+		data:      unsafe.Pointer(C.CString(key.encode())),
 		free_func: nil,
 	}
 
@@ -361,14 +445,14 @@ func RegisterWrite(name string, w api.Writer) error {
 		return fmt.Errorf("register_write_wrapper failed with status %d", status)
 	}
 
-	writeFuncs[name] = w
+	writeFuncs[key] = w
 	return nil
 }
 
 //export wrap_write_callback
 func wrap_write_callback(ds *C.data_set_t, cvl *C.value_list_t, ud *C.user_data_t) C.int {
-	name := C.GoString((*C.char)(ud.data))
-	w, ok := writeFuncs[name]
+	key := decodeKey(C.GoString((*C.char)(ud.data)))
+	w, ok := writeFuncs[key]
 	if !ok {
 		return -1
 	}
@@ -400,7 +484,7 @@ func wrap_write_callback(ds *C.data_set_t, cvl *C.value_list_t, ud *C.user_data_
 			v := C.value_list_get_gauge(cvl, i)
 			vl.Values = append(vl.Values, api.Gauge(v))
 		default:
-			Errorf("%s plugin: data source type %d is not supported", name, dsrc._type)
+			Errorf("%s plugin: data source type %d is not supported", key.name, dsrc._type)
 			return -1
 		}
 
@@ -408,7 +492,7 @@ func wrap_write_callback(ds *C.data_set_t, cvl *C.value_list_t, ud *C.user_data_
 	}
 
 	if err := w.Write(ctx, vl); err != nil {
-		Errorf("%s plugin: Write() failed: %v", name, err)
+		Errorf("%s plugin: Write() failed: %v", key.name, err)
 		return -1
 	}
 
@@ -422,19 +506,22 @@ type Shutter interface {
 	Shutdown() error
 }
 
-// shutdownFuncs holds references to all shutdown callbacks
-var shutdownFuncs = make(map[string]Shutter)
+// shutdownFuncs holds references to all shutdown callbacks, keyed by the
+// pluginKey they were registered under, so that two plugins loaded into
+// the same process don't trigger each other's Shutdown().
+var shutdownFuncs = make(map[pluginKey]Shutter)
 
 //export wrap_shutdown_callback
-func wrap_shutdown_callback() C.int {
-	if len(shutdownFuncs) <= 0 {
+func wrap_shutdown_callback(ud *C.user_data_t) C.int {
+	key := decodeKey(C.GoString((*C.char)(ud.data)))
+	s, ok := shutdownFuncs[key]
+	if !ok {
 		return 0
 	}
-	for n, s := range shutdownFuncs {
-		if err := s.Shutdown(); err != nil {
-			Errorf("%s plugin: Shutdown() failed: %v", n, s)
-			return -1
-		}
+
+	if err := s.Shutdown(); err != nil {
+		Errorf("%s plugin: Shutdown() failed: %v", key.name, err)
+		return -1
 	}
 	return 0
 }
@@ -442,19 +529,22 @@ func wrap_shutdown_callback() C.int {
 // RegisterShutdown registers a shutdown function with the daemon which is called
 // when the plugin is required to shutdown gracefully.
 func RegisterShutdown(name string, s Shutter) error {
-	// Only register a callback the first time one is implemented, subsequent
-	// callbacks get added to a list and called at the same time
-	if len(shutdownFuncs) <= 0 {
-		cName := C.CString(name)
-		cCallback := C.plugin_shutdown_cb(C.wrap_shutdown_callback)
+	cName := C.CString(name)
 
-		status, err := C.register_shutdown_wrapper(cName, cCallback)
-		if err != nil {
-			Errorf("register_shutdown_wrapper failed with status: %v", status)
-			return err
-		}
+	key := pluginKey{path: callerPluginPath(1), name: name}
+	ud := C.user_data_t{
+		data:      unsafe.Pointer(C.CString(key.encode())),
+		free_func: nil,
 	}
-	shutdownFuncs[name] = s
+
+	status, err := C.register_shutdown_wrapper(cName,
+		C.plugin_shutdown_cb(C.wrap_shutdown_callback), &ud)
+	if err != nil {
+		Errorf("register_shutdown_wrapper failed with status: %v", status)
+		return err
+	}
+
+	shutdownFuncs[key] = s
 	return nil
 }
 
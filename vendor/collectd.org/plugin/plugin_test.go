@@ -0,0 +1,47 @@
+// +build go1.5,cgo
+
+package plugin
+
+import "testing"
+
+func TestPluginKeyRoundTrip(t *testing.T) {
+	cases := []pluginKey{
+		{path: "collectd.org/plugin", name: "example"},
+		{path: "example.com/goplug", name: "cpu"},
+		{name: "no-path"},
+	}
+
+	for _, want := range cases {
+		got := decodeKey(want.encode())
+		if got != want {
+			t.Errorf("decodeKey(%q) = %+v, want %+v", want.encode(), got, want)
+		}
+	}
+}
+
+func TestDecodeKeyWithoutSeparator(t *testing.T) {
+	// A string that holds no keySeparator at all decodes to a key with an
+	// empty path and the whole string as its name.
+	got := decodeKey("justaname")
+	want := pluginKey{name: "justaname"}
+	if got != want {
+		t.Errorf("decodeKey(%q) = %+v, want %+v", "justaname", got, want)
+	}
+}
+
+func TestFuncImportPath(t *testing.T) {
+	cases := []struct {
+		funcName string
+		want     string
+	}{
+		{funcName: "collectd.org/plugin.RegisterRead", want: "collectd.org/plugin"},
+		{funcName: "example.com/goplug.(*ExamplePlugin).Read", want: "example.com/goplug"},
+		{funcName: "main.init", want: "main.init"},
+	}
+
+	for _, c := range cases {
+		if got := funcImportPath(c.funcName); got != c.want {
+			t.Errorf("funcImportPath(%q) = %q, want %q", c.funcName, got, c.want)
+		}
+	}
+}
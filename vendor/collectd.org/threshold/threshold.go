@@ -0,0 +1,351 @@
+// +build go1.5,cgo
+
+// Package threshold implements a threshold evaluation subsystem for
+// pure-Go collectd plugins. It mirrors the semantics of collectd's
+// built-in "threshold" plugin: warning/failure bounds, hysteresis,
+// percentage normalization and an optional linear-regression trend
+// predictor, evaluated against every ValueList dispatched through this
+// package's Write or a Writer returned by its NewWriter. ValueLists
+// passed to plugin.Write directly are not evaluated.
+package threshold
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"collectd.org/api"
+	"collectd.org/plugin"
+)
+
+// defaultPredictSamples is used when a Rule enables Predict without
+// specifying PredictSamples.
+const defaultPredictSamples = 10
+
+// Rule describes the bounds a matching ValueList is evaluated against.
+//
+// Host, Plugin, PluginInstance, Type and TypeInstance are matched against
+// the corresponding fields of an api.Identifier; an empty field matches
+// any value. DataSource selects which value of a multi-value ValueList,
+// by DSName, the bounds apply to; if empty, the rule applies to every
+// value in the ValueList.
+type Rule struct {
+	Host, Plugin, PluginInstance, Type, TypeInstance string
+	DataSource                                       string
+
+	WarningMin, WarningMax *float64
+	FailureMin, FailureMax *float64
+
+	// Hysteresis keeps a Rule that has already fired from flapping: once a
+	// bound has been crossed, the value must cross back over bound∓
+	// Hysteresis before the state returns to OKAY.
+	Hysteresis float64
+
+	// Percentage normalizes every value of a ValueList to a sum of 100
+	// before comparing them against the bounds.
+	Percentage bool
+
+	// Persist dispatches a notification on every evaluation, not just on
+	// state changes. PersistOK additionally dispatches a notification
+	// while the state is (still) OKAY.
+	Persist   bool
+	PersistOK bool
+
+	// Predict enables the linear-regression trend predictor: on every
+	// evaluation, a line is fit over the last PredictSamples values (10 by
+	// default) seen for the matched identifier and WarningMin/WarningMax
+	// are evaluated against the value predicted at now+PredictLookahead.
+	Predict          bool
+	PredictSamples   int
+	PredictLookahead time.Duration
+}
+
+func (r Rule) matches(id api.Identifier) bool {
+	return matchField(r.Host, id.Host) &&
+		matchField(r.Plugin, id.Plugin) &&
+		matchField(r.PluginInstance, id.PluginInstance) &&
+		matchField(r.Type, id.Type) &&
+		matchField(r.TypeInstance, id.TypeInstance)
+}
+
+func matchField(pattern, value string) bool {
+	return pattern == "" || pattern == value
+}
+
+var (
+	mu     sync.Mutex
+	rules  []Rule
+	states = make(map[string]*state)
+	rings  = make(map[string]*ring)
+)
+
+// Register adds rule to the set of thresholds evaluated against every
+// ValueList dispatched through Write or a Writer returned by NewWriter.
+func Register(rule Rule) error {
+	if rule.Predict && rule.PredictSamples <= 0 {
+		rule.PredictSamples = defaultPredictSamples
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	rules = append(rules, rule)
+	return nil
+}
+
+// state tracks the last severity reported for a given identifier and
+// data source, so transitions and hysteresis can be evaluated.
+type state struct {
+	last api.Severity
+}
+
+// Write evaluates vl against every registered Rule and then forwards it
+// to plugin.Write.
+func Write(vl *api.ValueList) error {
+	evaluate(vl)
+	return plugin.Write(vl)
+}
+
+// NewWriter returns an api.Writer that evaluates every ValueList against
+// the registered Rules before forwarding it to plugin.NewWriter().
+func NewWriter() api.Writer {
+	return writer{next: plugin.NewWriter()}
+}
+
+type writer struct {
+	next api.Writer
+}
+
+func (w writer) Write(ctx context.Context, vl *api.ValueList) error {
+	evaluate(vl)
+	return w.next.Write(ctx, vl)
+}
+
+func evaluate(vl *api.ValueList) {
+	mu.Lock()
+	rs := make([]Rule, len(rules))
+	copy(rs, rules)
+	mu.Unlock()
+
+	if len(rs) == 0 {
+		return
+	}
+
+	raw := rawValues(vl)
+
+	for _, r := range rs {
+		if !r.matches(vl.Identifier) {
+			continue
+		}
+
+		values := raw
+		if r.Percentage {
+			values = toPercentages(raw)
+		}
+
+		for i, v := range values {
+			if r.DataSource != "" && (i >= len(vl.DSNames) || vl.DSNames[i] != r.DataSource) {
+				continue
+			}
+
+			ds := r.DataSource
+			if ds == "" && i < len(vl.DSNames) {
+				ds = vl.DSNames[i]
+			}
+
+			evaluateValue(r, key(vl.Identifier, ds), vl.Identifier, v, vl.Time)
+		}
+	}
+}
+
+func key(id api.Identifier, ds string) string {
+	return fmt.Sprintf("%s/%s-%s/%s-%s/%s",
+		id.Host, id.Plugin, id.PluginInstance, id.Type, id.TypeInstance, ds)
+}
+
+func rawValues(vl *api.ValueList) []float64 {
+	out := make([]float64, len(vl.Values))
+	for i, v := range vl.Values {
+		out[i] = toFloat(v)
+	}
+
+	return out
+}
+
+// toPercentages scales values so that they sum to 100, implementing
+// Rule.Percentage. If the values sum to zero, they are returned
+// unscaled, since there is no meaningful percentage to compute.
+func toPercentages(values []float64) []float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	if sum == 0 {
+		return values
+	}
+
+	out := make([]float64, len(values))
+	for i, v := range values {
+		out[i] = 100 * v / sum
+	}
+	return out
+}
+
+func toFloat(v api.Value) float64 {
+	switch v := v.(type) {
+	case api.Gauge:
+		return float64(v)
+	case api.Counter:
+		return float64(v)
+	case api.Derive:
+		return float64(v)
+	default:
+		return 0
+	}
+}
+
+// boundBreached reports whether v lies outside [min, max], tightening the
+// bound that was already breached by hysteresis to avoid flapping.
+func boundBreached(v, hysteresis float64, min, max *float64, wasBreached bool) bool {
+	if min != nil {
+		bound := *min
+		if wasBreached {
+			bound += hysteresis
+		}
+		if v < bound {
+			return true
+		}
+	}
+	if max != nil {
+		bound := *max
+		if wasBreached {
+			bound -= hysteresis
+		}
+		if v > bound {
+			return true
+		}
+	}
+	return false
+}
+
+func evaluateSeverity(r Rule, v float64, last api.Severity) api.Severity {
+	if boundBreached(v, r.Hysteresis, r.FailureMin, r.FailureMax, last == api.SeverityFailure) {
+		return api.SeverityFailure
+	}
+	if boundBreached(v, r.Hysteresis, r.WarningMin, r.WarningMax, last == api.SeverityWarning) {
+		return api.SeverityWarning
+	}
+	return api.SeverityOkay
+}
+
+// predictedSeverity reports the severity a linear-regression trend
+// predictor sees for the bounds of r at now+r.PredictLookahead.
+func predictedSeverity(r Rule, k string, v float64, t time.Time) api.Severity {
+	mu.Lock()
+	rb, ok := rings[k]
+	if !ok {
+		rb = &ring{max: r.PredictSamples}
+		rings[k] = rb
+	}
+	rb.add(sample{t: t, v: v})
+	predicted, ok := rb.predict(t.Add(r.PredictLookahead))
+	mu.Unlock()
+
+	if !ok {
+		return api.SeverityOkay
+	}
+	if boundBreached(predicted, 0, r.WarningMin, r.WarningMax, false) {
+		return api.SeverityWarning
+	}
+	return api.SeverityOkay
+}
+
+func evaluateValue(r Rule, k string, id api.Identifier, v float64, t time.Time) {
+	mu.Lock()
+	st, ok := states[k]
+	if !ok {
+		st = &state{last: api.SeverityOkay}
+		states[k] = st
+	}
+	last := st.last
+	mu.Unlock()
+
+	sev := evaluateSeverity(r, v, last)
+	if r.Predict {
+		predicted := predictedSeverity(r, k, v, t)
+		if sev == api.SeverityOkay {
+			sev = predicted
+		}
+	}
+
+	mu.Lock()
+	st.last = sev
+	mu.Unlock()
+
+	changed := sev != last
+	if !changed && !r.Persist && !(r.PersistOK && sev == api.SeverityOkay) {
+		return
+	}
+
+	n := &api.Notification{
+		Identifier: id,
+		Severity:   sev,
+		Time:       t,
+		Message: fmt.Sprintf("%s: value %v is now %s (was %s)",
+			k, v, sev, last),
+	}
+	if err := plugin.DispatchNotification(n); err != nil {
+		plugin.Errorf("threshold: DispatchNotification failed: %v", err)
+	}
+}
+
+// sample is a single (time, value) pair used by the trend predictor's
+// ring buffer.
+type sample struct {
+	t time.Time
+	v float64
+}
+
+// ring is a fixed-size ring buffer of samples per identifier/data-source,
+// used to fit the linear-regression trend predictor.
+type ring struct {
+	samples []sample
+	max     int
+}
+
+func (r *ring) add(s sample) {
+	r.samples = append(r.samples, s)
+	if len(r.samples) > r.max {
+		r.samples = r.samples[len(r.samples)-r.max:]
+	}
+}
+
+// predict fits y = a + b·t over the buffered samples using ordinary
+// least squares and returns the predicted value at t.
+func (r *ring) predict(t time.Time) (float64, bool) {
+	n := len(r.samples)
+	if n < 2 {
+		return 0, false
+	}
+
+	t0 := r.samples[0].t
+	var sumT, sumV, sumTT, sumTV float64
+	for _, s := range r.samples {
+		x := s.t.Sub(t0).Seconds()
+		sumT += x
+		sumV += s.v
+		sumTT += x * x
+		sumTV += x * s.v
+	}
+
+	nf := float64(n)
+	denom := nf*sumTT - sumT*sumT
+	if denom == 0 {
+		return 0, false
+	}
+
+	b := (nf*sumTV - sumT*sumV) / denom
+	a := (sumV - b*sumT) / nf
+
+	return a + b*t.Sub(t0).Seconds(), true
+}
@@ -0,0 +1,104 @@
+// +build go1.5,cgo
+
+package threshold
+
+import (
+	"testing"
+	"time"
+
+	"collectd.org/api"
+)
+
+func f(v float64) *float64 { return &v }
+
+func TestBoundBreached(t *testing.T) {
+	cases := []struct {
+		name          string
+		v, hysteresis float64
+		min, max      *float64
+		wasBreached   bool
+		wantBreached  bool
+	}{
+		{name: "within bounds", v: 5, min: f(0), max: f(10), wantBreached: false},
+		{name: "below min", v: -1, min: f(0), max: f(10), wantBreached: true},
+		{name: "above max", v: 11, min: f(0), max: f(10), wantBreached: true},
+		{name: "no bounds set", v: 1000, wantBreached: false},
+		{
+			name: "hysteresis keeps a breach sticky", v: 9.5, min: f(0), max: f(10),
+			hysteresis: 1, wasBreached: true, wantBreached: true,
+		},
+		{
+			name: "hysteresis doesn't widen a fresh breach", v: 10.5, min: f(0), max: f(10),
+			hysteresis: 1, wasBreached: false, wantBreached: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := boundBreached(c.v, c.hysteresis, c.min, c.max, c.wasBreached)
+			if got != c.wantBreached {
+				t.Errorf("boundBreached(%v) = %v, want %v", c.v, got, c.wantBreached)
+			}
+		})
+	}
+}
+
+func TestEvaluateSeverity(t *testing.T) {
+	r := Rule{
+		WarningMin: f(10), WarningMax: f(90),
+		FailureMin: f(0), FailureMax: f(100),
+	}
+
+	cases := []struct {
+		v    float64
+		want api.Severity
+	}{
+		{v: 50, want: api.SeverityOkay},
+		{v: 5, want: api.SeverityWarning},
+		{v: -5, want: api.SeverityFailure},
+		{v: 150, want: api.SeverityFailure},
+	}
+
+	for _, c := range cases {
+		if got := evaluateSeverity(r, c.v, api.SeverityOkay); got != c.want {
+			t.Errorf("evaluateSeverity(%v) = %v, want %v", c.v, got, c.want)
+		}
+	}
+}
+
+func TestToPercentages(t *testing.T) {
+	got := toPercentages([]float64{1, 1, 2})
+	want := []float64{25, 25, 50}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("toPercentages()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	if got := toPercentages([]float64{0, 0}); got[0] != 0 || got[1] != 0 {
+		t.Errorf("toPercentages() with a zero sum should return the input unscaled, got %v", got)
+	}
+}
+
+func TestRingPredict(t *testing.T) {
+	r := &ring{max: 10}
+	t0 := time.Unix(0, 0)
+	for i := 0; i < 5; i++ {
+		r.add(sample{t: t0.Add(time.Duration(i) * time.Second), v: float64(i)})
+	}
+
+	got, ok := r.predict(t0.Add(10 * time.Second))
+	if !ok {
+		t.Fatalf("predict() = _, false, want true")
+	}
+	if want := 10.0; got != want {
+		t.Errorf("predict() = %v, want %v", got, want)
+	}
+}
+
+func TestRingPredictNotEnoughSamples(t *testing.T) {
+	r := &ring{max: 10}
+	if _, ok := r.predict(time.Unix(0, 0)); ok {
+		t.Error("predict() with fewer than two samples: got ok=true, want false")
+	}
+}